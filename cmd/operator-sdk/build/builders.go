@@ -0,0 +1,388 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/google/shlex"
+)
+
+// BuildOptions holds everything an ImageBuilder needs to produce an image.
+type BuildOptions struct {
+	Context    string
+	Dockerfile string
+	Image      string
+	Platforms  []string
+	BuildArgs  string
+	IIDFile    string
+
+	// Push, when set, asks the backend to push Image to its registry once
+	// it is built. RegistryAuthFile is used by the podman, buildah and
+	// imagebuilder pushes, TLSVerify by the podman and buildah pushes.
+	Push             bool
+	RegistryAuthFile string
+	TLSVerify        bool
+
+	// Secrets are build-time secrets in "id=<id>,src=<path>" form, mounted
+	// into RUN steps without being persisted into the image. Only honored
+	// by backends whose RunInProcess is true.
+	Secrets []string
+}
+
+// ImageBuilder is a pluggable backend for `operator-sdk build`. Backends are
+// registered in imageBuilders and selected with --image-builder.
+type ImageBuilder interface {
+	// Name is the --image-builder value that selects this backend.
+	Name() string
+	// Build returns the sequence of commands that produce opts.Image, and,
+	// if opts.Push is set, push it to its registry.
+	Build(opts BuildOptions) ([]*exec.Cmd, error)
+	// SupportsMultiArch reports whether this backend can build a
+	// manifest-list image for more than one platform.
+	SupportsMultiArch() bool
+	// RequiresDaemon reports whether this backend needs a running image
+	// daemon (e.g. the docker daemon) rather than running entirely as an
+	// unprivileged client process.
+	RequiresDaemon() bool
+	// RunInProcess reports whether this backend executes the build inside
+	// the operator-sdk process itself via BuildInProcess, rather than
+	// shelling out to an external command returned from Build.
+	RunInProcess() bool
+	// BuildInProcess performs the build without shelling out. Only called
+	// when RunInProcess returns true.
+	BuildInProcess(opts BuildOptions) error
+}
+
+// externalBuilder is embedded by backends that shell out to an external
+// command and so never run in-process.
+type externalBuilder struct{}
+
+func (externalBuilder) RunInProcess() bool { return false }
+
+func (externalBuilder) BuildInProcess(opts BuildOptions) error {
+	return fmt.Errorf("this image builder does not support in-process builds")
+}
+
+// imageBuilders is the registry of available --image-builder backends.
+var imageBuilders = map[string]ImageBuilder{}
+
+func registerImageBuilder(b ImageBuilder) {
+	imageBuilders[b.Name()] = b
+}
+
+func init() {
+	registerImageBuilder(dockerBuilder{})
+	registerImageBuilder(podmanBuilder{})
+	registerImageBuilder(buildahBuilder{})
+	registerImageBuilder(kanikoBuilder{})
+	registerImageBuilder(buildkitBuilder{})
+	registerImageBuilder(imgBuilder{})
+	registerImageBuilder(imagebuilderBuilder{})
+}
+
+// getImageBuilder looks up a registered ImageBuilder by name.
+func getImageBuilder(name string) (ImageBuilder, error) {
+	b, ok := imageBuilders[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a supported image builder", name)
+	}
+	return b, nil
+}
+
+// splitBuildArgs shlex-splits a single "--image-build-args" string into argv.
+func splitBuildArgs(buildArgs string) ([]string, error) {
+	if buildArgs == "" {
+		return nil, nil
+	}
+	args, err := shlex.Split(buildArgs)
+	if err != nil {
+		return nil, fmt.Errorf("image-build-args is not parseable: %v", err)
+	}
+	return args, nil
+}
+
+// dockerBuilder shells out to the docker CLI, using buildx for multi-arch
+// manifest-list builds.
+type dockerBuilder struct{ externalBuilder }
+
+func (dockerBuilder) Name() string            { return "docker" }
+func (dockerBuilder) SupportsMultiArch() bool { return true }
+func (dockerBuilder) RequiresDaemon() bool    { return true }
+
+func (dockerBuilder) Build(opts BuildOptions) ([]*exec.Cmd, error) {
+	bargs, err := splitBuildArgs(opts.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Platforms) > 0 {
+		if err := ensureBuildxBuilder(); err != nil {
+			return nil, err
+		}
+		args := []string{"buildx", "build", "--platform", strings.Join(opts.Platforms, ",")}
+		if opts.Push {
+			// buildx cannot --load a multi-platform manifest list into the
+			// local daemon; it must be pushed directly to the registry.
+			args = append(args, "--push")
+		} else {
+			args = append(args, "--load")
+		}
+		args = append(args, "-f", opts.Dockerfile, "-t", opts.Image)
+		args = append(args, bargs...)
+		if opts.IIDFile != "" {
+			args = append(args, "--iidfile", opts.IIDFile)
+		}
+		args = append(args, opts.Context)
+		return []*exec.Cmd{exec.Command("docker", args...)}, nil
+	}
+
+	args := append([]string{"build", "-f", opts.Dockerfile, "-t", opts.Image}, bargs...)
+	if opts.IIDFile != "" {
+		args = append(args, "--iidfile", opts.IIDFile)
+	}
+	args = append(args, opts.Context)
+	cmds := []*exec.Cmd{exec.Command("docker", args...)}
+	if opts.Push {
+		cmds = append(cmds, exec.Command("docker", "push", opts.Image))
+	}
+	return cmds, nil
+}
+
+// podmanBuilder shells out to the podman CLI.
+type podmanBuilder struct{ externalBuilder }
+
+func (podmanBuilder) Name() string            { return "podman" }
+func (podmanBuilder) SupportsMultiArch() bool { return true }
+func (podmanBuilder) RequiresDaemon() bool    { return false }
+
+func (podmanBuilder) Build(opts BuildOptions) ([]*exec.Cmd, error) {
+	bargs, err := splitBuildArgs(opts.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Platforms) > 0 {
+		return buildahStyleManifest("podman", opts, bargs)
+	}
+
+	args := append([]string{"build", "-f", opts.Dockerfile, "-t", opts.Image}, bargs...)
+	args = append(args, opts.Context)
+	cmds := []*exec.Cmd{exec.Command("podman", args...)}
+	if opts.Push {
+		cmds = append(cmds, exec.Command("podman", pushArgs(opts)...))
+	}
+	return cmds, nil
+}
+
+// buildahBuilder shells out to the buildah CLI.
+type buildahBuilder struct{ externalBuilder }
+
+func (buildahBuilder) Name() string            { return "buildah" }
+func (buildahBuilder) SupportsMultiArch() bool { return true }
+func (buildahBuilder) RequiresDaemon() bool    { return false }
+
+func (buildahBuilder) Build(opts BuildOptions) ([]*exec.Cmd, error) {
+	bargs, err := splitBuildArgs(opts.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Platforms) > 0 {
+		return buildahStyleManifest("buildah", opts, bargs)
+	}
+
+	args := append([]string{"bud", "--format=docker", "-f", opts.Dockerfile, "-t", opts.Image}, bargs...)
+	args = append(args, opts.Context)
+	cmds := []*exec.Cmd{exec.Command("buildah", args...)}
+	if opts.Push {
+		cmds = append(cmds, exec.Command("buildah", pushArgs(opts)...))
+	}
+	return cmds, nil
+}
+
+// pushArgs builds the `push --authfile ... --tls-verify=... image` argv
+// shared by the podman and buildah single-arch push steps.
+func pushArgs(opts BuildOptions) []string {
+	args := []string{"push"}
+	if opts.RegistryAuthFile != "" {
+		args = append(args, "--authfile", opts.RegistryAuthFile)
+	}
+	args = append(args, fmt.Sprintf("--tls-verify=%t", opts.TLSVerify))
+	return append(args, opts.Image)
+}
+
+// buildahStyleManifest builds one image per platform with builder
+// ("buildah" or "podman") and stitches them into a manifest list with
+// `buildah manifest create`/`manifest add`, since podman defers manifest
+// management to buildah as well.
+func buildahStyleManifest(builder string, opts BuildOptions, bargs []string) ([]*exec.Cmd, error) {
+	var cmds []*exec.Cmd
+	var archImages []string
+	for _, platform := range opts.Platforms {
+		p, err := parsePlatform(platform)
+		if err != nil {
+			return nil, err
+		}
+		archImage := fmt.Sprintf("%s-%s", opts.Image, p.arch)
+		archImages = append(archImages, archImage)
+
+		var args []string
+		if builder == "buildah" {
+			args = append(args, "bud", "--format=docker", "--os", p.os, "--arch", p.arch)
+			if p.arm != "" {
+				args = append(args, "--variant", "v"+p.arm)
+			}
+		} else {
+			args = append(args, "build", "--platform", platform)
+		}
+		args = append(args, "-f", opts.Dockerfile, "-t", archImage)
+		// buildah/podman don't auto-populate TARGETOS/TARGETARCH build args
+		// the way buildx does, so pass them explicitly for a Dockerfile
+		// using the standard TARGETARCH/TARGETOS COPY pattern.
+		args = append(args, "--build-arg", "TARGETOS="+p.os, "--build-arg", "TARGETARCH="+p.arch)
+		args = append(args, bargs...)
+		args = append(args, opts.Context)
+		cmds = append(cmds, exec.Command(builder, args...))
+	}
+
+	manifestArgs := []string{"manifest", "create"}
+	if opts.IIDFile != "" {
+		// --iidfile is only accepted by `manifest create` (and bud/build/commit),
+		// not by `manifest inspect`.
+		manifestArgs = append(manifestArgs, "--iidfile", opts.IIDFile)
+	}
+	manifestArgs = append(manifestArgs, opts.Image)
+	manifestArgs = append(manifestArgs, archImages...)
+	cmds = append(cmds, exec.Command("buildah", manifestArgs...))
+	for _, archImage := range archImages {
+		cmds = append(cmds, exec.Command("buildah", "manifest", "add", opts.Image, archImage))
+	}
+	if opts.Push {
+		args := []string{"manifest", "push", "--all"}
+		if opts.RegistryAuthFile != "" {
+			args = append(args, "--authfile", opts.RegistryAuthFile)
+		}
+		args = append(args, fmt.Sprintf("--tls-verify=%t", opts.TLSVerify))
+		args = append(args, opts.Image, "docker://"+opts.Image)
+		cmds = append(cmds, exec.Command("buildah", args...))
+	}
+	return cmds, nil
+}
+
+// ensureBuildxBuilder makes sure a docker buildx builder instance exists so
+// that `buildx build --platform` can run without a prior one-time `docker
+// buildx create`.
+func ensureBuildxBuilder() error {
+	if err := exec.Command("docker", "buildx", "inspect", "operator-sdk-builder").Run(); err == nil {
+		return nil
+	}
+	create := exec.Command("docker", "buildx", "create", "--name", "operator-sdk-builder", "--use")
+	create.Stdout, create.Stderr = os.Stdout, os.Stderr
+	return create.Run()
+}
+
+// kanikoBuilder runs /kaniko/executor, the daemonless builder used inside
+// CI and rootless in-cluster pods. kaniko has no local image store, so
+// --destination is always pushed to the registry; --push is implied.
+type kanikoBuilder struct{ externalBuilder }
+
+func (kanikoBuilder) Name() string            { return "kaniko" }
+func (kanikoBuilder) SupportsMultiArch() bool { return false }
+func (kanikoBuilder) RequiresDaemon() bool    { return false }
+
+func (kanikoBuilder) Build(opts BuildOptions) ([]*exec.Cmd, error) {
+	if len(opts.Platforms) > 1 {
+		return nil, fmt.Errorf("kaniko does not support multi-architecture builds via --platform")
+	}
+	bargs, err := splitBuildArgs(opts.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{
+		fmt.Sprintf("--dockerfile=%s", opts.Dockerfile),
+		fmt.Sprintf("--context=%s", opts.Context),
+		fmt.Sprintf("--destination=%s", opts.Image),
+	}
+	if len(opts.Platforms) == 1 {
+		args = append(args, fmt.Sprintf("--custom-platform=%s", opts.Platforms[0]))
+	}
+	args = append(args, bargs...)
+	return []*exec.Cmd{exec.Command("/kaniko/executor", args...)}, nil
+}
+
+// buildkitBuilder drives a buildkitd daemon via buildctl.
+type buildkitBuilder struct{ externalBuilder }
+
+func (buildkitBuilder) Name() string            { return "buildkit" }
+func (buildkitBuilder) SupportsMultiArch() bool { return false }
+func (buildkitBuilder) RequiresDaemon() bool    { return true }
+
+func (buildkitBuilder) Build(opts BuildOptions) ([]*exec.Cmd, error) {
+	if len(opts.Platforms) > 1 {
+		return nil, fmt.Errorf("buildkit does not support multi-architecture builds via --platform")
+	}
+	bargs, err := splitBuildArgs(opts.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+	output := "type=image,name=" + opts.Image
+	if opts.Push {
+		output += ",push=true"
+	}
+	args := []string{
+		"build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + opts.Context,
+		"--local", "dockerfile=" + opts.Dockerfile,
+		"--output", output,
+	}
+	if len(opts.Platforms) == 1 {
+		args = append(args, "--opt", "platform="+opts.Platforms[0])
+	}
+	args = append(args, bargs...)
+	return []*exec.Cmd{exec.Command("buildctl", args...)}, nil
+}
+
+// imgBuilder shells out to genuinetools/img, a daemonless, rootless builder.
+type imgBuilder struct{ externalBuilder }
+
+func (imgBuilder) Name() string            { return "img" }
+func (imgBuilder) SupportsMultiArch() bool { return false }
+func (imgBuilder) RequiresDaemon() bool    { return false }
+
+func (imgBuilder) Build(opts BuildOptions) ([]*exec.Cmd, error) {
+	if len(opts.Platforms) > 1 {
+		return nil, fmt.Errorf("img does not support multi-architecture builds via --platform")
+	}
+	bargs, err := splitBuildArgs(opts.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"build", "-f", opts.Dockerfile, "-t", opts.Image}
+	if len(opts.Platforms) == 1 {
+		args = append(args, "--platform", opts.Platforms[0])
+	}
+	args = append(args, bargs...)
+	args = append(args, opts.Context)
+	cmds := []*exec.Cmd{exec.Command("img", args...)}
+	if opts.Push {
+		cmds = append(cmds, exec.Command("img", "push", opts.Image))
+	}
+	return cmds, nil
+}
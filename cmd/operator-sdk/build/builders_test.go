@@ -0,0 +1,191 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cmdArgs joins each *exec.Cmd's Args (including the command name in Args[0])
+// into one space-separated string per command, for easy substring assertions
+// in these tests.
+func cmdArgs(cmds []*exec.Cmd) []string {
+	var out []string
+	for _, c := range cmds {
+		out = append(out, strings.Join(c.Args, " "))
+	}
+	return out
+}
+
+func TestPushArgs(t *testing.T) {
+	cases := []struct {
+		name string
+		opts BuildOptions
+		want []string
+	}{
+		{
+			name: "no auth file, tls-verify true",
+			opts: BuildOptions{Image: "quay.io/example/operator:v0.0.1", TLSVerify: true},
+			want: []string{"push", "--tls-verify=true", "quay.io/example/operator:v0.0.1"},
+		},
+		{
+			name: "auth file set, tls-verify false",
+			opts: BuildOptions{Image: "quay.io/example/operator:v0.0.1", RegistryAuthFile: "/tmp/auth.json", TLSVerify: false},
+			want: []string{"push", "--authfile", "/tmp/auth.json", "--tls-verify=false", "quay.io/example/operator:v0.0.1"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := pushArgs(c.opts)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("pushArgs() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBuildahStyleManifestSingleArch(t *testing.T) {
+	opts := BuildOptions{
+		Context:    ".",
+		Dockerfile: "build/Dockerfile",
+		Image:      "quay.io/example/operator:v0.0.1",
+		Platforms:  []string{"linux/amd64"},
+	}
+
+	cmds, err := buildahStyleManifest("buildah", opts, nil)
+	if err != nil {
+		t.Fatalf("buildahStyleManifest: unexpected error: %v", err)
+	}
+	args := cmdArgs(cmds)
+
+	build := args[0]
+	for _, want := range []string{"--os linux", "--arch amd64", "TARGETOS=linux", "TARGETARCH=amd64", "-t quay.io/example/operator:v0.0.1-amd64"} {
+		if !strings.Contains(build, want) {
+			t.Errorf("per-arch build command %q does not contain %q", build, want)
+		}
+	}
+
+	joined := strings.Join(args, "\n")
+	if !strings.Contains(joined, "manifest create quay.io/example/operator:v0.0.1 quay.io/example/operator:v0.0.1-amd64") {
+		t.Errorf("expected a manifest create command, got:\n%s", joined)
+	}
+	if !strings.Contains(joined, "manifest add quay.io/example/operator:v0.0.1 quay.io/example/operator:v0.0.1-amd64") {
+		t.Errorf("expected a manifest add command, got:\n%s", joined)
+	}
+}
+
+func TestBuildahStyleManifestVariant(t *testing.T) {
+	opts := BuildOptions{
+		Context:    ".",
+		Dockerfile: "build/Dockerfile",
+		Image:      "quay.io/example/operator:v0.0.1",
+		Platforms:  []string{"linux/arm/v7"},
+	}
+
+	cmds, err := buildahStyleManifest("buildah", opts, nil)
+	if err != nil {
+		t.Fatalf("buildahStyleManifest: unexpected error: %v", err)
+	}
+	build := cmdArgs(cmds)[0]
+	if !strings.Contains(build, "--variant v7") {
+		t.Errorf("per-arch build command %q does not contain --variant v7", build)
+	}
+}
+
+func TestBuildahStyleManifestPodmanUsesBuildahForManifest(t *testing.T) {
+	opts := BuildOptions{
+		Context:    ".",
+		Dockerfile: "build/Dockerfile",
+		Image:      "quay.io/example/operator:v0.0.1",
+		Platforms:  []string{"linux/amd64", "linux/arm64"},
+	}
+
+	cmds, err := buildahStyleManifest("podman", opts, nil)
+	if err != nil {
+		t.Fatalf("buildahStyleManifest: unexpected error: %v", err)
+	}
+	for i, platform := range opts.Platforms {
+		if cmds[i].Path != "/usr/bin/podman" && !strings.HasSuffix(cmds[i].Path, "podman") {
+			t.Errorf("build command %d: got binary %q, want podman", i, cmds[i].Path)
+		}
+		if !strings.Contains(strings.Join(cmds[i].Args, " "), "--platform "+platform) {
+			t.Errorf("build command %d %q does not contain --platform %s", i, cmds[i].Args, platform)
+		}
+	}
+	// manifest create/add always shell out to buildah, even for the podman builder.
+	for _, c := range cmds[len(opts.Platforms):] {
+		if !strings.HasSuffix(c.Path, "buildah") {
+			t.Errorf("manifest command %v should use buildah, got %q", c.Args, c.Path)
+		}
+	}
+}
+
+func TestBuildahStyleManifestPush(t *testing.T) {
+	opts := BuildOptions{
+		Context:          ".",
+		Dockerfile:       "build/Dockerfile",
+		Image:            "quay.io/example/operator:v0.0.1",
+		Platforms:        []string{"linux/amd64"},
+		Push:             true,
+		RegistryAuthFile: "/tmp/auth.json",
+		TLSVerify:        false,
+	}
+
+	cmds, err := buildahStyleManifest("buildah", opts, nil)
+	if err != nil {
+		t.Fatalf("buildahStyleManifest: unexpected error: %v", err)
+	}
+	last := cmds[len(cmds)-1]
+	got := strings.Join(last.Args, " ")
+	for _, want := range []string{"manifest push --all", "--authfile /tmp/auth.json", "--tls-verify=false", "docker://quay.io/example/operator:v0.0.1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("push command %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestBuildahStyleManifestIIDFile(t *testing.T) {
+	opts := BuildOptions{
+		Context:    ".",
+		Dockerfile: "build/Dockerfile",
+		Image:      "quay.io/example/operator:v0.0.1",
+		Platforms:  []string{"linux/amd64", "linux/arm64"},
+		IIDFile:    "/tmp/iid.txt",
+	}
+
+	cmds, err := buildahStyleManifest("buildah", opts, nil)
+	if err != nil {
+		t.Fatalf("buildahStyleManifest: unexpected error: %v", err)
+	}
+
+	// the manifest create command immediately follows the per-arch build commands.
+	manifestCreate := cmds[len(opts.Platforms)]
+	got := strings.Join(manifestCreate.Args, " ")
+	if !strings.HasPrefix(got, "buildah manifest create --iidfile /tmp/iid.txt ") {
+		t.Errorf("manifest create command = %q, want --iidfile on `manifest create`", got)
+	}
+
+	// --iidfile must never appear on a `manifest inspect` invocation.
+	for _, c := range cmds {
+		args := strings.Join(c.Args, " ")
+		if strings.Contains(args, "manifest inspect") {
+			t.Errorf("unexpected `manifest inspect` command %q; --iidfile is read from `manifest create` now", args)
+		}
+	}
+}
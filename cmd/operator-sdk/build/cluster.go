@@ -0,0 +1,106 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// loadImageIntoCluster imports image into the local dev cluster named
+// target without a registry round-trip: a kind cluster if target names one,
+// otherwise a minikube profile.
+func loadImageIntoCluster(imageBuilderName, image, target string) error {
+	if isKindCluster(target) {
+		return loadImageIntoKind(imageBuilderName, image, target)
+	}
+	return loadImageIntoMinikube(image, target)
+}
+
+// isKindCluster reports whether name appears in `kind get clusters`. If kind
+// isn't installed or has no clusters, target is assumed to be a minikube
+// profile instead.
+func isKindCluster(name string) bool {
+	out, err := exec.Command("kind", "get", "clusters").Output()
+	if err != nil {
+		return false
+	}
+	for _, cluster := range strings.Fields(string(out)) {
+		if cluster == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loadImageIntoKind saves image to a docker-archive tarball and imports it
+// into the kind cluster clusterName with `kind load image-archive`.
+func loadImageIntoKind(imageBuilderName, image, clusterName string) error {
+	tmpFile, err := ioutil.TempFile("", "operator-sdk-build-*.tar")
+	if err != nil {
+		return err
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	save, err := saveImageCommand(imageBuilderName, image, tmpFile.Name())
+	if err != nil {
+		return err
+	}
+	save.Stdout, save.Stderr = os.Stdout, os.Stderr
+	if err := save.Run(); err != nil {
+		return fmt.Errorf("failed to save image %s: %v", image, err)
+	}
+
+	load := exec.Command("kind", "load", "image-archive", tmpFile.Name(), "--name", clusterName)
+	load.Stdout, load.Stderr = os.Stdout, os.Stderr
+	if err := load.Run(); err != nil {
+		return fmt.Errorf("failed to load image %s into kind cluster %s: %v", image, clusterName, err)
+	}
+	return nil
+}
+
+// loadImageIntoMinikube imports image into the minikube profile with
+// `minikube image load`, passing the image by name; no tarball is saved
+// first and the --image-builder backend is not consulted.
+func loadImageIntoMinikube(image, profile string) error {
+	load := exec.Command("minikube", "image", "load", image, "-p", profile)
+	load.Stdout, load.Stderr = os.Stdout, os.Stderr
+	if err := load.Run(); err != nil {
+		return fmt.Errorf("failed to load image %s into minikube profile %s: %v", image, profile, err)
+	}
+	return nil
+}
+
+// saveImageCommand returns the command that writes image as a
+// docker-archive tarball to dest, for the given --image-builder backend.
+func saveImageCommand(imageBuilderName, image, dest string) (*exec.Cmd, error) {
+	switch imageBuilderName {
+	case "docker", "imagebuilder":
+		// imagebuilder builds and tags through the same local docker daemon
+		// client as the docker backend, so its images are docker-save-able too.
+		return exec.Command("docker", "save", "-o", dest, image), nil
+	case "podman":
+		return exec.Command("podman", "save", "-o", dest, image), nil
+	case "buildah":
+		// buildah has no "save" subcommand; push to a docker-archive instead.
+		return exec.Command("buildah", "push", image, "docker-archive:"+dest), nil
+	default:
+		return nil, fmt.Errorf("--load-into is not supported for image builder %s", imageBuilderName)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSaveImageCommand(t *testing.T) {
+	cases := []struct {
+		imageBuilderName string
+		wantBin          string
+		wantErr          bool
+	}{
+		{imageBuilderName: "docker", wantBin: "docker"},
+		{imageBuilderName: "imagebuilder", wantBin: "docker"},
+		{imageBuilderName: "podman", wantBin: "podman"},
+		{imageBuilderName: "buildah", wantBin: "buildah"},
+		{imageBuilderName: "kaniko", wantErr: true},
+	}
+
+	for _, c := range cases {
+		cmd, err := saveImageCommand(c.imageBuilderName, "quay.io/example/operator:v0.0.1", "/tmp/out.tar")
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("saveImageCommand(%q): expected an error, got none", c.imageBuilderName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("saveImageCommand(%q): unexpected error: %v", c.imageBuilderName, err)
+			continue
+		}
+		if !strings.HasSuffix(cmd.Path, c.wantBin) {
+			t.Errorf("saveImageCommand(%q): got binary %q, want %q", c.imageBuilderName, cmd.Path, c.wantBin)
+		}
+		if !strings.Contains(strings.Join(cmd.Args, " "), "/tmp/out.tar") {
+			t.Errorf("saveImageCommand(%q): args %v don't reference the dest path", c.imageBuilderName, cmd.Args)
+		}
+	}
+}
+
+func TestIsKindCluster(t *testing.T) {
+	// kind isn't installed in the test environment, so `kind get clusters`
+	// fails and every name should be treated as a minikube profile.
+	if isKindCluster("some-cluster") {
+		t.Errorf("isKindCluster(%q) = true, want false when kind is unavailable", "some-cluster")
+	}
+}
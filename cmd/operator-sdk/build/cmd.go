@@ -17,7 +17,6 @@ package build
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
@@ -25,18 +24,62 @@ import (
 	"github.com/operator-framework/operator-sdk/internal/scaffold"
 	"github.com/operator-framework/operator-sdk/internal/util/projutil"
 
-	"github.com/google/shlex"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
-	imageBuildArgs string
-	imageBuilder   string
-	goBuildArgs    string
-	skipImage      bool
+	imageBuildArgs   string
+	imageBuilder     string
+	goBuildArgs      string
+	skipImage        bool
+	platforms        []string
+	iidfile          string
+	push             bool
+	registryAuthFile string
+	tlsVerify        bool
+	loadInto         string
+	secrets          []string
 )
 
+// platformBuild describes a single GOOS/GOARCH/GOARM combination derived
+// from a `--platform` value such as "linux/arm/v7".
+type platformBuild struct {
+	os, arch, arm string
+}
+
+// goEnv returns the GOOS/GOARCH/GOARM environment variables for this platform.
+func (p platformBuild) goEnv() []string {
+	env := []string{"GOOS=" + p.os, "GOARCH=" + p.arch}
+	if p.arm != "" {
+		env = append(env, "GOARM="+p.arm)
+	}
+	return env
+}
+
+// dir is the per-platform subdirectory of scaffold.BuildBinDir the binary
+// for this platform is written to, e.g. "linux_arm64".
+func (p platformBuild) dir() string {
+	if p.arm != "" {
+		return fmt.Sprintf("%s_%s_v%s", p.os, p.arch, p.arm)
+	}
+	return fmt.Sprintf("%s_%s", p.os, p.arch)
+}
+
+// parsePlatform splits a "<os>/<arch>[/<variant>]" string, e.g.
+// "linux/arm64" or "linux/arm/v7", into a platformBuild.
+func parsePlatform(platform string) (platformBuild, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return platformBuild{}, fmt.Errorf("%s is not a valid platform, expected <os>/<arch>[/<variant>]", platform)
+	}
+	p := platformBuild{os: parts[0], arch: parts[1]}
+	if len(parts) == 3 {
+		p.arm = strings.TrimPrefix(parts[2], "v")
+	}
+	return p, nil
+}
+
 func NewCmd() *cobra.Command {
 	buildCmd := &cobra.Command{
 		Use:   "build [<image>]",
@@ -48,9 +91,8 @@ and generates the Dockerfile manifest.
 By default, this image will be automatically set in the deployment manifests. Note that you can use
 the flag --skip-image to skip building the container image and only build the operator binary.
 
-After build completes, the image would be built locally in docker. Then it needs to
-be pushed to remote registry.
-For example:
+After build completes, the image is built locally. Pass --push to push it to its
+remote registry immediately, or push it yourself afterwards:
 
 	$ operator-sdk build quay.io/example/operator:v0.0.1
 	$ docker push quay.io/example/operator:v0.0.1
@@ -60,47 +102,58 @@ For example:
 	buildCmd.Flags().StringVar(&imageBuildArgs, "image-build-args", "",
 		"Extra image build arguments as one string such as \"--build-arg https_proxy=$https_proxy\"")
 	buildCmd.Flags().StringVar(&imageBuilder, "image-builder", "docker",
-		"Tool to build OCI images. One of: [docker, podman, buildah]")
+		"Tool to build OCI images. One of: [docker, podman, buildah, kaniko, buildkit, img, imagebuilder]")
 	buildCmd.Flags().StringVar(&goBuildArgs, "go-build-args", "",
 		"Extra Go build arguments as one string such as \"-ldflags -X=main.xyz=abc\"")
 	buildCmd.Flags().BoolVar(&skipImage, "skip-image", false,
 		"If set, only the operator binary is built and the container image build is skipped.")
+	buildCmd.Flags().StringSliceVar(&platforms, "platform", nil,
+		"Platforms to build a multi-architecture manifest-list image for, e.g. "+
+			"\"linux/amd64,linux/arm64,linux/ppc64le,linux/s390x\". May be repeated. "+
+			"If unset, an image for the local platform is built.")
+	buildCmd.Flags().StringVar(&iidfile, "iidfile", "",
+		"Write the built image or manifest-list digest to this file. Honored by the docker builder "+
+			"and by buildah/podman builds with --platform; other builds ignore it.")
+	buildCmd.Flags().BoolVar(&push, "push", false,
+		"Push the built image to its registry once the build succeeds")
+	buildCmd.Flags().StringVar(&registryAuthFile, "registry-auth-file", "",
+		"Path to a registry auth file to use when --push is set, as accepted by podman/buildah --authfile")
+	buildCmd.Flags().BoolVar(&tlsVerify, "tls-verify", true,
+		"Require HTTPS and verify certificates when --push is set (podman/buildah builders only)")
+	buildCmd.Flags().StringVar(&loadInto, "load-into", "",
+		"Name of a local kind cluster or minikube profile to load the built image into directly, "+
+			"skipping a registry round-trip")
+	buildCmd.Flags().StringArrayVar(&secrets, "secret", nil,
+		"Build-time secret as \"id=mysecret,src=/local/path\", mounted into RUN steps without "+
+			"being persisted into the image. Only supported by --image-builder=imagebuilder. May be repeated.")
 	return buildCmd
 }
 
-func createBuildCommand(imageBuilder, context, dockerFile, image string, imageBuildArgs ...string) (*exec.Cmd, error) {
-	var args []string
-	switch imageBuilder {
-	case "docker", "podman":
-		args = append(args, "build", "-f", dockerFile, "-t", image)
-	case "buildah":
-		args = append(args, "bud", "--format=docker", "-f", dockerFile, "-t", image)
-	default:
-		return nil, fmt.Errorf("%s is not supported image builder", imageBuilder)
+// hasDockerDaemon is a best-effort check for a reachable docker-compatible
+// daemon, used to warn early when a builder that RequiresDaemon is selected
+// in an environment (e.g. a rootless in-cluster pod) that has none.
+func hasDockerDaemon() bool {
+	if os.Getenv("DOCKER_HOST") != "" {
+		return true
 	}
-
-	for _, bargs := range imageBuildArgs {
-		if bargs != "" {
-			splitArgs, err := shlex.Split(bargs)
-			if err != nil {
-				return nil, fmt.Errorf("image-build-args is not parseable: %v", err)
-			}
-			args = append(args, splitArgs...)
-		}
-	}
-
-	args = append(args, context)
-
-	return exec.Command(imageBuilder, args...), nil
+	_, err := os.Stat("/var/run/docker.sock")
+	return err == nil
 }
 
 func buildFunc(cmd *cobra.Command, args []string) error {
+	projutil.MustInProjectRoot()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %v", configFileName, err)
+	}
+	args = cfg.restore(cmd, args)
+
 	if len(args) != 1 && !skipImage {
 		return fmt.Errorf("command %s requires exactly one argument or --skip-image", cmd.CommandPath())
 	}
 
-	projutil.MustInProjectRoot()
-	goBuildEnv := append(os.Environ(), "GOOS=linux")
+	goBuildEnv := os.Environ()
 
 	// If CGO_ENABLED is not set, set it to '0'.
 	if _, ok := os.LookupEnv("CGO_ENABLED"); !ok {
@@ -113,36 +166,119 @@ func buildFunc(cmd *cobra.Command, args []string) error {
 	// Don't need to build Go code if a non-Go Operator.
 	if projutil.IsOperatorGo() {
 		trimPath := fmt.Sprintf("all=-trimpath=%s", filepath.Dir(absProjectPath))
-		args := []string{"-gcflags", trimPath, "-asmflags", trimPath}
+		goArgs := []string{"-gcflags", trimPath, "-asmflags", trimPath}
 
 		if goBuildArgs != "" {
 			splitArgs := strings.Fields(goBuildArgs)
-			args = append(args, splitArgs...)
+			goArgs = append(goArgs, splitArgs...)
 		}
 
-		opts := projutil.GoCmdOptions{
-			BinName:     filepath.Join(absProjectPath, scaffold.BuildBinDir, projectName),
-			PackagePath: path.Join(projutil.GetGoPkg(), filepath.ToSlash(scaffold.ManagerDir)),
-			Args:        args,
-			Env:         goBuildEnv,
-		}
-		if err := projutil.GoBuild(opts); err != nil {
-			return fmt.Errorf("failed to build operator binary: %v", err)
+		packagePath := path.Join(projutil.GetGoPkg(), filepath.ToSlash(scaffold.ManagerDir))
+
+		if len(platforms) == 0 {
+			opts := projutil.GoCmdOptions{
+				BinName:     filepath.Join(absProjectPath, scaffold.BuildBinDir, projectName),
+				PackagePath: packagePath,
+				Args:        goArgs,
+				Env:         append(goBuildEnv, "GOOS=linux"),
+			}
+			if err := projutil.GoBuild(opts); err != nil {
+				return fmt.Errorf("failed to build operator binary: %v", err)
+			}
+		} else {
+			for _, platform := range platforms {
+				p, err := parsePlatform(platform)
+				if err != nil {
+					return err
+				}
+				opts := projutil.GoCmdOptions{
+					BinName:     filepath.Join(absProjectPath, scaffold.BuildBinDir, p.dir(), projectName),
+					PackagePath: packagePath,
+					Args:        goArgs,
+					Env:         append(goBuildEnv, p.goEnv()...),
+				}
+				if err := projutil.GoBuild(opts); err != nil {
+					return fmt.Errorf("failed to build operator binary for platform %s: %v", platform, err)
+				}
+			}
 		}
 	}
 
 	if !skipImage {
 		image := args[0]
 
-		log.Infof("Building OCI image %s", image)
-
-		buildCmd, err := createBuildCommand(imageBuilder, ".", "build/Dockerfile", image, imageBuildArgs)
+		builder, err := getImageBuilder(imageBuilder)
 		if err != nil {
 			return err
 		}
+		if len(platforms) > 1 && !builder.SupportsMultiArch() {
+			return fmt.Errorf("image builder %s does not support multi-architecture builds via --platform", imageBuilder)
+		}
+		if builder.RequiresDaemon() && !hasDockerDaemon() {
+			log.Warnf("image builder %s requires a running image daemon, but none was detected "+
+				"(no $DOCKER_HOST and /var/run/docker.sock not found); the build may fail. "+
+				"Consider --image-builder=kaniko, buildah, podman, or img for daemonless builds.", imageBuilder)
+		}
+		if imageBuilder == "kaniko" && !push {
+			// kaniko has no local image store: --destination is always pushed
+			// to the registry, regardless of --push.
+			log.Warnf("image builder kaniko always pushes %s to its registry; --push was not set but the build will push anyway", image)
+		}
 
-		if err := projutil.ExecCmd(buildCmd); err != nil {
-			return fmt.Errorf("failed to output build image %s: %v", image, err)
+		if len(platforms) > 1 {
+			log.Infof("Building multi-architecture OCI image %s for platforms %s", image, strings.Join(platforms, ","))
+		} else {
+			log.Infof("Building OCI image %s", image)
+		}
+
+		// With --platform, each binary is written to
+		// scaffold.BuildBinDir/<os>_<arch>[_v<variant>]/<project> (see
+		// platformBuild.dir above); without it, to
+		// scaffold.BuildBinDir/<project>. For a multi-platform build,
+		// build/Dockerfile declares `ARG TARGETOS` and `ARG TARGETARCH` and
+		// COPYs the binary from the per-platform directory. docker buildx
+		// populates these args automatically; buildahStyleManifest passes
+		// TARGETOS and TARGETARCH explicitly via --build-arg for the
+		// buildah/podman backends, and passes no variant.
+		buildOpts := BuildOptions{
+			Context:          ".",
+			Dockerfile:       "build/Dockerfile",
+			Image:            image,
+			Platforms:        platforms,
+			BuildArgs:        imageBuildArgs,
+			IIDFile:          iidfile,
+			Push:             push,
+			RegistryAuthFile: registryAuthFile,
+			TLSVerify:        tlsVerify,
+			Secrets:          secrets,
+		}
+
+		if builder.RunInProcess() {
+			if err := builder.BuildInProcess(buildOpts); err != nil {
+				return fmt.Errorf("failed to build image %s: %v", image, err)
+			}
+		} else {
+			buildCmds, err := builder.Build(buildOpts)
+			if err != nil {
+				return err
+			}
+
+			for _, buildCmd := range buildCmds {
+				if err := projutil.ExecCmd(buildCmd); err != nil {
+					return fmt.Errorf("failed to output build image %s: %v", image, err)
+				}
+			}
+		}
+
+		if push {
+			log.Infof("Pushed image %s", image)
+		}
+
+		if loadInto != "" {
+			log.Infof("Loading image %s into %s", image, loadInto)
+			if err := loadImageIntoCluster(imageBuilder, image, loadInto); err != nil {
+				return err
+			}
 		}
 	} else {
 		log.Infof("Skipping image building")
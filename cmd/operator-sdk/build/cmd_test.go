@@ -0,0 +1,90 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	cases := []struct {
+		platform string
+		want     platformBuild
+		wantErr  bool
+	}{
+		{platform: "linux/amd64", want: platformBuild{os: "linux", arch: "amd64"}},
+		{platform: "linux/arm64", want: platformBuild{os: "linux", arch: "arm64"}},
+		{platform: "linux/arm/v7", want: platformBuild{os: "linux", arch: "arm", arm: "7"}},
+		{platform: "linux", wantErr: true},
+		{platform: "linux/arm/v7/extra", wantErr: true},
+		{platform: "", wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := parsePlatform(c.platform)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parsePlatform(%q): expected an error, got none", c.platform)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePlatform(%q): unexpected error: %v", c.platform, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parsePlatform(%q) = %+v, want %+v", c.platform, got, c.want)
+		}
+	}
+}
+
+func TestPlatformBuildGoEnv(t *testing.T) {
+	cases := []struct {
+		name string
+		p    platformBuild
+		want []string
+	}{
+		{name: "no variant", p: platformBuild{os: "linux", arch: "amd64"}, want: []string{"GOOS=linux", "GOARCH=amd64"}},
+		{name: "with variant", p: platformBuild{os: "linux", arch: "arm", arm: "7"}, want: []string{"GOOS=linux", "GOARCH=arm", "GOARM=7"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.p.goEnv(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("goEnv() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPlatformBuildDir(t *testing.T) {
+	cases := []struct {
+		name string
+		p    platformBuild
+		want string
+	}{
+		{name: "no variant", p: platformBuild{os: "linux", arch: "amd64"}, want: "linux_amd64"},
+		{name: "with variant", p: platformBuild{os: "linux", arch: "arm", arm: "7"}, want: "linux_arm_v7"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.p.dir(); got != c.want {
+				t.Errorf("dir() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
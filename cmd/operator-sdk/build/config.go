@@ -0,0 +1,87 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// configFileName is the repo-root config file `build` reads its defaults
+// from, modeled on s2i's `.s2ifile`.
+const configFileName = ".operator-sdk.yaml"
+
+// config is the schema of .operator-sdk.yaml. Only the Build section is
+// read by this command.
+type config struct {
+	Build buildConfig `yaml:"build"`
+}
+
+// buildConfig holds defaults for the flags of `operator-sdk build`, so teams
+// can pin reproducible build invocations without wrapping the command in a
+// Makefile.
+type buildConfig struct {
+	Image          string   `yaml:"image"`
+	ImageBuilder   string   `yaml:"image-builder"`
+	ImageBuildArgs string   `yaml:"image-build-args"`
+	GoBuildArgs    string   `yaml:"go-build-args"`
+	Platforms      []string `yaml:"platforms"`
+	Push           bool     `yaml:"push"`
+}
+
+// loadConfig reads configFileName from the current directory. A missing
+// file is not an error; it simply means no defaults are applied.
+func loadConfig() (*config, error) {
+	b, err := ioutil.ReadFile(configFileName)
+	if os.IsNotExist(err) {
+		return &config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// restore prefills cmd's build flags from cfg.Build, skipping any flag the
+// user already set explicitly on the command line. Mirrors s2i's `Restore`,
+// which does the same for `.s2ifile`.
+func (cfg *config) restore(cmd *cobra.Command, args []string) []string {
+	if !cmd.Flag("image-builder").Changed && cfg.Build.ImageBuilder != "" {
+		imageBuilder = cfg.Build.ImageBuilder
+	}
+	if !cmd.Flag("image-build-args").Changed && cfg.Build.ImageBuildArgs != "" {
+		imageBuildArgs = cfg.Build.ImageBuildArgs
+	}
+	if !cmd.Flag("go-build-args").Changed && cfg.Build.GoBuildArgs != "" {
+		goBuildArgs = cfg.Build.GoBuildArgs
+	}
+	if !cmd.Flag("platform").Changed && len(cfg.Build.Platforms) > 0 {
+		platforms = cfg.Build.Platforms
+	}
+	if !cmd.Flag("push").Changed && cfg.Build.Push {
+		push = cfg.Build.Push
+	}
+	if len(args) == 0 && cfg.Build.Image != "" {
+		args = []string{cfg.Build.Image}
+	}
+	return args
+}
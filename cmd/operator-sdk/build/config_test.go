@@ -0,0 +1,105 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+// resetBuildFlags clears the package-level flag-backing globals touched by
+// restore, so each test starts from a clean slate. Tests then call NewCmd
+// for a fresh *cobra.Command.
+func resetBuildFlags() {
+	imageBuilder = ""
+	imageBuildArgs = ""
+	goBuildArgs = ""
+	platforms = nil
+	push = false
+}
+
+func TestConfigRestore(t *testing.T) {
+	t.Run("file values fill unset flags", func(t *testing.T) {
+		resetBuildFlags()
+		cmd := NewCmd()
+		cfg := &config{Build: buildConfig{
+			Image:        "quay.io/example/operator:v0.0.1",
+			ImageBuilder: "podman",
+			Platforms:    []string{"linux/amd64", "linux/arm64"},
+			Push:         true,
+		}}
+
+		args := cfg.restore(cmd, nil)
+
+		if !reflect.DeepEqual(args, []string{"quay.io/example/operator:v0.0.1"}) {
+			t.Errorf("args = %v, want the config file image as the sole arg", args)
+		}
+		if imageBuilder != "podman" {
+			t.Errorf("imageBuilder = %q, want %q", imageBuilder, "podman")
+		}
+		if !reflect.DeepEqual(platforms, []string{"linux/amd64", "linux/arm64"}) {
+			t.Errorf("platforms = %v, want the config file's platforms", platforms)
+		}
+		if !push {
+			t.Errorf("push = false, want true from the config file")
+		}
+	})
+
+	t.Run("explicit flags win over the config file", func(t *testing.T) {
+		resetBuildFlags()
+		cmd := NewCmd()
+		if err := cmd.Flags().Set("image-builder", "docker"); err != nil {
+			t.Fatalf("failed to set --image-builder: %v", err)
+		}
+
+		cfg := &config{Build: buildConfig{ImageBuilder: "podman"}}
+		cfg.restore(cmd, []string{"explicit-image:v1"})
+
+		if imageBuilder != "docker" {
+			t.Errorf("imageBuilder = %q, want %q (flag should win over config file)", imageBuilder, "docker")
+		}
+	})
+
+	t.Run("explicit arg wins over the config file image", func(t *testing.T) {
+		resetBuildFlags()
+		cmd := NewCmd()
+		cfg := &config{Build: buildConfig{Image: "quay.io/example/operator:v0.0.1"}}
+
+		args := cfg.restore(cmd, []string{"explicit-image:v1"})
+
+		if !reflect.DeepEqual(args, []string{"explicit-image:v1"}) {
+			t.Errorf("args = %v, want the explicit arg preserved", args)
+		}
+	})
+
+	t.Run("zero-value config fields leave flag defaults untouched", func(t *testing.T) {
+		resetBuildFlags()
+		imageBuilder = "docker"
+		cmd := NewCmd()
+		cfg := &config{}
+
+		args := cfg.restore(cmd, nil)
+
+		if len(args) != 0 {
+			t.Errorf("args = %v, want empty when neither args nor config file set an image", args)
+		}
+		if imageBuilder != "docker" {
+			t.Errorf("imageBuilder = %q, want unchanged default %q", imageBuilder, "docker")
+		}
+		if push {
+			t.Errorf("push = true, want unchanged default false")
+		}
+	})
+}
@@ -0,0 +1,36 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import "testing"
+
+func TestRegistryHostFromImage(t *testing.T) {
+	cases := []struct {
+		image string
+		want  string
+	}{
+		{image: "quay.io/example/operator:v0.0.1", want: "quay.io"},
+		{image: "localhost:5000/example/operator:v0.0.1", want: "localhost:5000"},
+		{image: "localhost/example/operator:v0.0.1", want: "localhost"},
+		{image: "example/operator:v0.0.1", want: "docker.io"},
+		{image: "operator:v0.0.1", want: "docker.io"},
+	}
+
+	for _, c := range cases {
+		if got := registryHostFromImage(c.image); got != c.want {
+			t.Errorf("registryHostFromImage(%q) = %q, want %q", c.image, got, c.want)
+		}
+	}
+}